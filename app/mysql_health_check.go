@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"math"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-ini/ini"
@@ -19,15 +27,27 @@ import (
 
 // HTTPResponse Structure used to define response object of every route request
 type HTTPResponse struct {
-	Status  bool   `json:"status"`
-	Content string `json:"content"`
+	Status     bool      `json:"status"`
+	Code       int       `json:"code"`
+	Content    string    `json:"content"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+	DurationMs int64     `json:"duration_ms"`
 }
 
-// SlaveStatus Structure used to save output from 'SHOW SLAVE STATUS' queries
-type SlaveStatus struct {
-	masterHost    string
-	masterPort    string
-	secondsMaster string
+// ReplicaChannel Structure used to save one row of 'SHOW [SLAVE|REPLICA] STATUS' output.
+// Channel is empty on single-source replicas; multi-source setups populate it from Channel_Name.
+type ReplicaChannel struct {
+	Channel       string
+	SourceHost    string
+	SourcePort    string
+	SecondsBehind string
+}
+
+// HealthCheckResult Structure used to name one entry of the /health aggregate response
+type HealthCheckResult struct {
+	Check string `json:"check"`
+	HTTPResponse
 }
 
 const (
@@ -45,10 +65,54 @@ var (
 	listenAddr   = flag.String("listen-address", "0.0.0.0", "Address where API is listening for requests")
 	listenPort   = flag.Int("listen-port", 3307, "Port where API is listening for requests")
 
-	db  *sql.DB
-	lag int
+	tlsCert   = flag.String("tls-cert", "", "Path to TLS certificate file, enables HTTPS when set alongside --tls-key")
+	tlsKey    = flag.String("tls-key", "", "Path to TLS private key file")
+	tlsCA     = flag.String("tls-ca", "", "Path to a PEM CA bundle used to verify client certificates")
+	mutualTLS = flag.Bool("mutual-tls", false, "Require a valid client certificate signed by --tls-ca")
+
+	httpAuthUser     = flag.String("http-auth-user", "", "Username required for HTTP Basic Auth (disabled if empty and --http-auth-file is unset)")
+	httpAuthPassword = flag.String("http-auth-password", "", "Password required for HTTP Basic Auth")
+	httpAuthFile     = flag.String("http-auth-file", "", "Path to a .htpasswd-style \"user:password\" file, checked instead of --http-auth-user/--http-auth-password")
+
+	enableMetrics   = flag.Bool("enable-metrics", true, "Expose a Prometheus-compatible /metrics endpoint")
+	metricsInterval = flag.Duration("metrics-interval", 15*time.Second, "Interval between background db.Ping/status polls that feed /metrics gauges")
+	enablePprof     = flag.Bool("enable-pprof", false, "Register net/http/pprof handlers under /debug/pprof/")
+
+	dbMaxOpenConns    = flag.Int("db-max-open-conns", 10, "Maximum number of open connections to the database")
+	dbMaxIdleConns    = flag.Int("db-max-idle-conns", 5, "Maximum number of idle connections to keep in the pool")
+	dbConnMaxLifetime = flag.Duration("db-conn-max-lifetime", 5*time.Minute, "Maximum amount of time a database connection may be reused")
+	queryTimeout      = flag.Duration("query-timeout", 2*time.Second, "Per-request timeout applied to every database call")
+
+	db *sql.DB
+
+	// showReplicaStatusStmt is picked at startup by detectReplicaStatusStatement: MySQL 8.0.22+
+	// deprecated `show slave status` in favour of `show replica status`.
+	showReplicaStatusStmt = "show slave status"
+
+	httpAuthCredentials map[string]string
+
+	routeMetricsMu sync.Mutex
+	routeCounters  = map[string]*routeMetric{}
+
+	mysqlUp       int32
+	secondsBehind int64 = -1
+	wsrepState    int64 = -1
+	replicaCount  int64
 )
 
+// histogramBucketsMs are the cumulative upper bounds, in milliseconds, of the buckets
+// exposed for mysql_health_api_http_request_duration_ms. A final implicit +Inf bucket
+// is appended so every observation is counted.
+var histogramBucketsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// routeMetric Accumulates a request-duration histogram for one route, for /metrics.
+// buckets holds cumulative counts aligned with histogramBucketsMs plus a trailing +Inf bucket.
+type routeMetric struct {
+	count   uint64
+	totalMs uint64
+	buckets []uint64
+}
+
 func main() {
 	flag.Parse()
 
@@ -56,6 +120,20 @@ func main() {
 		log.Fatal(err)
 	}
 
+	defer db.Close()
+
+	db.SetMaxOpenConns(*dbMaxOpenConns)
+	db.SetMaxIdleConns(*dbMaxIdleConns)
+	db.SetConnMaxLifetime(*dbConnMaxLifetime)
+
+	if err := loadHTTPAuthCredentials(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := detectReplicaStatusStatement(); err != nil {
+		log.Printf("Could not detect MySQL version, assuming `show slave status`: %s", err)
+	}
+
 	router := http.NewServeMux()
 
 	router.HandleFunc("/status/ro", RouteStatusReadOnly)
@@ -69,15 +147,53 @@ func main() {
 	router.HandleFunc("/role/replica", RouteRoleReplica)
 	router.HandleFunc("/role/replica/", RouteRoleReplicaByLag)
 	router.HandleFunc("/role/galera", RouteRoleGalera)
+	router.HandleFunc("/role/group_replication", RouteRoleGroupReplication)
 
 	router.HandleFunc("/read/galera/state", RouteReadGaleraState)
 	router.HandleFunc("/read/replication/lag", RouteReadReplicationLag)
 	router.HandleFunc("/read/replication/master", RouteReadReplicationMaster)
 	router.HandleFunc("/read/replication/replicas_count", RouteReadReplicasCounter)
 
+	router.HandleFunc("/health", RouteHealth)
+
+	if *enableMetrics {
+		router.HandleFunc("/metrics", RouteMetrics)
+		go collectMetrics(*metricsInterval)
+	}
+
+	if *enablePprof {
+		router.HandleFunc("/debug/pprof/", pprof.Index)
+		router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		router.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		router.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	handler := LogRequests(CheckURL(BasicAuth(router)))
+	addr := fmt.Sprintf("%s:%d", *listenAddr, *listenPort)
+
 	log.Printf("Listening on port %d ...", *listenPort)
 
-	if err := http.ListenAndServe(fmt.Sprintf("%s:%d", *listenAddr, *listenPort), LogRequests(CheckURL(router))); err != nil {
+	if *tlsCert != "" || *tlsKey != "" {
+		tlsConfig, err := buildTLSConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		server := &http.Server{
+			Addr:      addr,
+			Handler:   handler,
+			TLSConfig: tlsConfig,
+		}
+
+		if err := server.ListenAndServeTLS(*tlsCert, *tlsKey); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if err := http.ListenAndServe(addr, handler); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -93,30 +209,84 @@ func LogRequests(next http.Handler) http.Handler {
 
 		next.ServeHTTP(w, r)
 
+		elapsed := time.Since(start)
+
 		log.Printf(
 			"[%s]\t%s\t%s",
 			r.Method,
 			r.URL.String(),
-			time.Since(start),
+			elapsed,
 		)
+
+		recordRouteMetric(routeLabel(r.URL.Path), elapsed)
 	})
 }
 
-// CheckURL Middleware level to validate requested URI
+// knownRoutes lists every exact path registered on the router in main() outside /debug/pprof/
+// (which routeLabel matches by prefix), so routeLabel can tell a real route from client-supplied garbage
+var knownRoutes = map[string]bool{
+	"/status/ro":                       true,
+	"/status/rw":                       true,
+	"/status/single":                   true,
+	"/status/leader":                   true,
+	"/status/follower":                 true,
+	"/status/topology":                 true,
+	"/role/master":                     true,
+	"/role/replica":                    true,
+	"/role/galera":                     true,
+	"/role/group_replication":          true,
+	"/read/galera/state":               true,
+	"/read/replication/lag":            true,
+	"/read/replication/master":         true,
+	"/read/replication/replicas_count": true,
+	"/health":                          true,
+	"/metrics":                         true,
+}
+
+// routeLabel Collapses a request path to its registered route template, so /metrics labels
+// stay bounded regardless of client-supplied path segments: the /role/replica/{lag} lag value
+// and any /debug/pprof/* subpath are collapsed to their template, and anything unrecognized
+// (404s, probes) falls back to a single "other" label instead of growing the metric forever
+func routeLabel(path string) string {
+	const replicaByLagPrefix = "/role/replica/"
+	const pprofPrefix = "/debug/pprof/"
+
+	switch {
+	case strings.HasPrefix(path, replicaByLagPrefix):
+		return replicaByLagPrefix + "{lag}"
+	case strings.HasPrefix(path, pprofPrefix):
+		return pprofPrefix + "*"
+	case knownRoutes[path]:
+		return path
+	default:
+		return "other"
+	}
+}
+
+// CheckURL Middleware level to set the response content type
 func CheckURL(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.String()
-		pathLength := len(path)
-		matchPath := "/role/replica/"
-		matchLength := len(matchPath)
-
-		if strings.Contains(path, matchPath) && pathLength > matchLength {
-			lag, _ = strconv.Atoi(strings.Trim(path, matchPath))
-		} else if strings.Compare(path, strings.TrimRight(path, "/")) != 0 {
+		w.Header().Set(contentType, responseType)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BasicAuth Middleware level to require HTTP Basic Auth credentials, when configured
+func BasicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(httpAuthCredentials) == 0 {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		w.Header().Set(contentType, responseType)
+		user, pass, ok := r.BasicAuth()
+
+		if !ok || !validCredentials(user, pass) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="mysql_health_api"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
 
 		next.ServeHTTP(w, r)
 	})
@@ -136,6 +306,21 @@ func validateInputArgs() (bool, string) {
 		return false, errMsg
 	}
 
+	if (*tlsCert == "") != (*tlsKey == "") {
+		errMsg = "`--tls-cert` and `--tls-key` must be set together."
+		return false, errMsg
+	}
+
+	if (*mutualTLS || *tlsCA != "") && (*tlsCert == "" || *tlsKey == "") {
+		errMsg = "`--mutual-tls`/`--tls-ca` require `--tls-cert` and `--tls-key` to actually serve HTTPS. Refusing to start without enforcing it."
+		return false, errMsg
+	}
+
+	if *mutualTLS && *tlsCA == "" {
+		errMsg = "`--mutual-tls` requires `--tls-ca` to verify client certificates against. Refusing to start without enforcing it."
+		return false, errMsg
+	}
+
 	if *dbCnf != "" {
 		if _, err := os.Stat(*dbCnf); os.IsNotExist(err) {
 			errMsg = fmt.Sprintf("`%s`: Not found.", *dbCnf)
@@ -193,11 +378,145 @@ func validateInputArgs() (bool, string) {
 		return false, err.Error()
 	}
 
-	defer db.Close()
-
 	return true, ""
 }
 
+// recordRouteMetric Accumulate a served request's count and latency for /metrics
+func recordRouteMetric(route string, elapsed time.Duration) {
+	routeMetricsMu.Lock()
+	defer routeMetricsMu.Unlock()
+
+	m, ok := routeCounters[route]
+
+	if !ok {
+		m = &routeMetric{buckets: make([]uint64, len(histogramBucketsMs)+1)}
+		routeCounters[route] = m
+	}
+
+	elapsedMs := float64(elapsed.Milliseconds())
+
+	m.count++
+	m.totalMs += uint64(elapsed.Milliseconds())
+
+	for i, le := range histogramBucketsMs {
+		if elapsedMs <= le {
+			m.buckets[i]++
+		}
+	}
+
+	m.buckets[len(histogramBucketsMs)]++
+}
+
+// validCredentials Check a Basic Auth user/password pair against the configured credentials
+func validCredentials(user, pass string) bool {
+	want, ok := httpAuthCredentials[user]
+
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(pass), []byte(want)) == 1
+}
+
+// loadHTTPAuthCredentials populates httpAuthCredentials from --http-auth-file or
+// --http-auth-user/--http-auth-password. Leaves it empty (and Basic Auth disabled) when
+// neither is set.
+func loadHTTPAuthCredentials() error {
+	if *httpAuthFile != "" {
+		data, err := os.ReadFile(*httpAuthFile)
+		if err != nil {
+			return err
+		}
+
+		creds := make(map[string]string)
+
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			parts := strings.SplitN(line, ":", 2)
+
+			if len(parts) != 2 {
+				return fmt.Errorf("`%s`: malformed line %q, expected \"user:password\"", *httpAuthFile, line)
+			}
+
+			creds[parts[0]] = parts[1]
+		}
+
+		httpAuthCredentials = creds
+		return nil
+	}
+
+	if *httpAuthUser != "" {
+		httpAuthCredentials = map[string]string{*httpAuthUser: *httpAuthPassword}
+	}
+
+	return nil
+}
+
+// buildTLSConfig assembles the *tls.Config driven by --tls-ca and --mutual-tls
+func buildTLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if *tlsCA == "" {
+		return cfg, nil
+	}
+
+	caCert, err := os.ReadFile(*tlsCA)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("`%s`: no certificates found", *tlsCA)
+	}
+
+	cfg.ClientCAs = pool
+
+	if *mutualTLS {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
+// detectReplicaStatusStatement Selects `show replica status` on MySQL 8.0.22+ and
+// `show slave status` otherwise, by parsing the numeric prefix of @@version
+func detectReplicaStatusStatement() error {
+	var version string
+
+	if err := db.QueryRow("select @@version").Scan(&version); err != nil {
+		return err
+	}
+
+	numbers := strings.SplitN(strings.SplitN(version, "-", 2)[0], ".", 3)
+
+	if len(numbers) < 3 {
+		return fmt.Errorf("`%s`: unrecognized @@version format", version)
+	}
+
+	major, errMajor := strconv.Atoi(numbers[0])
+	minor, errMinor := strconv.Atoi(numbers[1])
+	patch, errPatch := strconv.Atoi(numbers[2])
+
+	if errMajor != nil || errMinor != nil || errPatch != nil {
+		return fmt.Errorf("`%s`: unrecognized @@version format", version)
+	}
+
+	if major > 8 || (major == 8 && (minor > 0 || patch >= 22)) {
+		showReplicaStatusStmt = "show replica status"
+	}
+
+	return nil
+}
+
 // int2bool Convert integers to boolean
 func int2bool(value int) bool {
 	if value != 0 {
@@ -207,68 +526,162 @@ func int2bool(value int) bool {
 	return false
 }
 
+// buildEnvelope Assembles the JSON response envelope common to every route, stamping
+// it with how long the check took so callers can spot slow checks without extra tooling
+func buildEnvelope(start time.Time, code int, status bool, content, errMsg string) HTTPResponse {
+	return HTTPResponse{
+		Status:     status,
+		Code:       code,
+		Content:    content,
+		Error:      errMsg,
+		CheckedAt:  start,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+}
+
+// writeEnvelope Marshals and writes an envelope built from buildEnvelope
+func writeEnvelope(w http.ResponseWriter, start time.Time, code int, status bool, content, errMsg string) {
+	res := buildEnvelope(start, code, status, content, errMsg)
+	response, _ := json.Marshal(res)
+
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "%s", response)
+}
+
 // routeResponse Used to build response to API requests
-func routeResponse(w http.ResponseWriter, httpStatus bool, contents string) {
-	res := new(HTTPResponse)
+func routeResponse(w http.ResponseWriter, start time.Time, httpStatus bool, contents string) {
+	code := http.StatusForbidden
 
 	if httpStatus {
-		w.WriteHeader(200)
-	} else {
-		w.WriteHeader(403)
+		code = http.StatusOK
 	}
 
-	res.Status = httpStatus
-	res.Content = contents
-	response, _ := json.Marshal(res)
-	fmt.Fprintf(w, "%s", response)
+	writeEnvelope(w, start, code, httpStatus, contents, "")
+}
+
+// routeUnavailable Used to respond 503 when a DB call fails (including running past --query-timeout),
+// as opposed to completing and reporting the database itself unhealthy
+func routeUnavailable(w http.ResponseWriter, start time.Time, err error) {
+	writeEnvelope(w, start, http.StatusServiceUnavailable, false, "", fmt.Sprintf("db unreachable: %s", err))
+}
+
+// routeBadRequest Used to respond 400 when the request itself is invalid (e.g. an unknown
+// replication channel), as opposed to the database being unreachable or unhealthy
+func routeBadRequest(w http.ResponseWriter, start time.Time, err error) {
+	writeEnvelope(w, start, http.StatusBadRequest, false, "", err.Error())
+}
+
+// handleDBError Writes the appropriate error envelope for a failed DB call and reports
+// whether it did so: 400 for an invalid request (e.g. ErrUnknownChannel), 503 otherwise
+func handleDBError(w http.ResponseWriter, start time.Time, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrUnknownChannel) {
+		routeBadRequest(w, start, err)
+		return true
+	}
+
+	routeUnavailable(w, start, err)
+	return true
 }
 
-// unknownColumns Used to get value from specific column of a range of unknown columns
-func unknownColumns(rows *sql.Rows) SlaveStatus {
-	columns, _ := rows.Columns()
-	count := len(columns)
-	values := make([]interface{}, count)
-	valuePtrs := make([]interface{}, count)
-	res := new(SlaveStatus)
+// queryContext Derive a per-request context bounded by --query-timeout
+func queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), *queryTimeout)
+}
+
+// ErrUnknownChannel is returned when a request's ?channel= does not match any replication channel
+var ErrUnknownChannel = errors.New("unknown replication channel")
+
+// parseReplicaChannels Reads every row of a 'SHOW [SLAVE|REPLICA] STATUS' result into a
+// []ReplicaChannel, understanding both the legacy (Master_*) and 8.0.22+ (Source_*) column
+// names so multi-source channels (keyed by Channel_Name) come back the same shape either way
+func parseReplicaChannels(rows *sql.Rows) ([]ReplicaChannel, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []ReplicaChannel
 
 	for rows.Next() {
-		for i := range columns {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+
+		for i := range values {
 			valuePtrs[i] = &values[i]
 		}
 
-		rows.Scan(valuePtrs...)
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+
+		var ch ReplicaChannel
 
 		for i, col := range columns {
+			var value string
 
-			var value interface{}
+			if b, ok := values[i].([]byte); ok {
+				value = string(b)
+			} else if values[i] != nil {
+				value = fmt.Sprintf("%v", values[i])
+			}
+
+			switch col {
+			case "Master_Host", "Source_Host":
+				ch.SourceHost = value
+			case "Master_Port", "Source_Port":
+				ch.SourcePort = value
+			case "Seconds_Behind_Master", "Seconds_Behind_Source":
+				ch.SecondsBehind = value
+			case "Channel_Name":
+				ch.Channel = value
+			}
+		}
 
-			val := values[i]
+		channels = append(channels, ch)
+	}
 
-			b, ok := val.([]byte)
+	return channels, rows.Err()
+}
 
-			if b == nil {
-				return *res
+// selectReplicaChannel Picks the requested channel by name, or (when name is empty) the
+// channel reporting the highest replication lag, matching the "?channel=" semantics shared
+// by the /read/replication/lag, /role/replica, and /status/follower routes
+func selectReplicaChannel(channels []ReplicaChannel, name string) (ReplicaChannel, error) {
+	if name != "" {
+		for _, ch := range channels {
+			if ch.Channel == name {
+				return ch, nil
 			}
+		}
 
-			if ok {
-				value = string(b)
-			} else {
-				value = val
-			}
+		return ReplicaChannel{}, fmt.Errorf("%w: %q", ErrUnknownChannel, name)
+	}
 
-			sNum := value.(string)
+	var best ReplicaChannel
+	bestLag := -1
+	found := false
 
-			if col == "Master_Host" {
-				res.masterHost = sNum
-			} else if col == "Master_Port" {
-				res.masterPort = sNum
-			} else if col == "Seconds_Behind_Master" {
-				res.secondsMaster = sNum
-			}
+	for _, ch := range channels {
+		lagValue, err := strconv.Atoi(ch.SecondsBehind)
+
+		if err != nil {
+			continue
 		}
+
+		if !found || lagValue > bestLag {
+			best, bestLag, found = ch, lagValue, true
+		}
+	}
+
+	if !found && len(channels) > 0 {
+		return channels[0], nil
 	}
 
-	return *res
+	return best, nil
 }
 
 /*
@@ -276,21 +689,34 @@ func unknownColumns(rows *sql.Rows) SlaveStatus {
  */
 
 // readOnly Check if database is in readonly mode, or not
-func readOnly() bool {
+func readOnly(ctx context.Context) (bool, error) {
 	var state string
 	var key string
 
-	err := db.QueryRow("show variables like 'read_only'").Scan(&key, &state)
+	if err := db.QueryRowContext(ctx, "show variables like 'read_only'").Scan(&key, &state); err != nil {
+		return false, err
+	}
 
-	if state == "OFF" || err != nil {
-		return false
+	return state != "OFF", nil
+}
+
+// replicationChannels Runs `show replica status` (MySQL 8.0.22+) or `show slave status`
+// (legacy), picked once at startup by detectReplicaStatusStatement
+func replicationChannels(ctx context.Context) ([]ReplicaChannel, error) {
+	rows, err := db.QueryContext(ctx, showReplicaStatusStmt)
+
+	if err != nil {
+		return nil, err
 	}
 
-	return true
+	defer rows.Close()
+
+	return parseReplicaChannels(rows)
 }
 
-// replicaStatus Read database status if it is a replica
-func replicaStatus(lagCount int) (bool, int) {
+// replicaStatus Read database replication status on the given channel ("" selects the
+// channel with the highest lag), reporting whether it is within lagCount seconds
+func replicaStatus(ctx context.Context, lagCount int, channel string) (bool, int, error) {
 	if lagCount == 0 {
 		if strconv.IntSize == 64 {
 			lagCount = math.MaxInt64
@@ -299,85 +725,143 @@ func replicaStatus(lagCount int) (bool, int) {
 		}
 	}
 
-	notSlave := false
-
-	rows, err := db.Query("show slave status")
-
+	channels, err := replicationChannels(ctx)
 	if err != nil {
-		return false, 0
+		return false, 0, err
 	}
 
-	defer rows.Close()
+	selected, err := selectReplicaChannel(channels, channel)
+	if err != nil {
+		return false, 0, err
+	}
 
-	slaveValues := unknownColumns(rows)
+	notSlave := selected.SecondsBehind == ""
+	secondsBehind := selected.SecondsBehind
 
-	if slaveValues.secondsMaster == "" {
-		notSlave = true
-		slaveValues.secondsMaster = "0"
+	if notSlave {
+		secondsBehind = "0"
 	}
 
-	lag, _ = strconv.Atoi(slaveValues.secondsMaster)
+	lag, _ := strconv.Atoi(secondsBehind)
 
 	if lag > 0 || !notSlave {
 		if lagCount > lag {
-			return true, lag
+			return true, lag, nil
 		}
 
-		return false, lag
+		return false, lag, nil
 	}
 
-	return false, 0
+	return false, 0, nil
 }
 
-// isReplica Get database's master, in case it is a replica
-func isReplica() (bool, string, string) {
-	rows, err := db.Query("show slave status")
+// isReplica Get database's source, in case it is a replica on the given channel ("" selects
+// the channel with the highest lag)
+func isReplica(ctx context.Context, channel string) (bool, string, string, error) {
+	channels, err := replicationChannels(ctx)
+	if err != nil {
+		return false, "", "", err
+	}
 
+	selected, err := selectReplicaChannel(channels, channel)
 	if err != nil {
-		return false, "", ""
+		return false, "", "", err
 	}
 
-	defer rows.Close()
+	if selected.SourceHost != "" {
+		return true, selected.SourceHost, selected.SourcePort, nil
+	}
+
+	return false, "", "", nil
+}
+
+// groupReplicationState Reports this member's Group Replication MEMBER_STATE, treating
+// ONLINE as healthy - the InnoDB Cluster equivalent of galeraClusterState
+func groupReplicationState(ctx context.Context) (bool, string, error) {
+	var state string
 
-	slaveValues := unknownColumns(rows)
+	err := db.QueryRowContext(ctx,
+		"select member_state "+
+			"from performance_schema.replication_group_members "+
+			"where member_id = @@server_uuid").Scan(&state)
 
-	if slaveValues.masterHost != "" {
-		return true, slaveValues.masterHost, slaveValues.masterPort
+	if err == sql.ErrNoRows {
+		return false, "", nil
+	}
+
+	if err != nil {
+		return false, "", err
 	}
 
-	return false, "", ""
+	return state == "ONLINE", state, nil
 }
 
 // servingBinlogs ...
-func servingBinlogs() int {
+func servingBinlogs(ctx context.Context) (int, error) {
 	var count int
 
-	err := db.QueryRow(
-		"select count(*) as n " +
-			"from information_schema.processlist " +
+	err := db.QueryRowContext(
+		ctx,
+		"select count(*) as n "+
+			"from information_schema.processlist "+
 			"where command = 'Binlog Dump'").Scan(&count)
 
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	return count
+	return count, nil
 }
 
 // galeraClusterState ...
-func galeraClusterState() (bool, string) {
+func galeraClusterState(ctx context.Context) (bool, string, error) {
 	var v string
 
-	err := db.QueryRow(
-		"select variable_value as v " +
-			"from information_schema.global_status " +
+	err := db.QueryRowContext(
+		ctx,
+		"select variable_value as v "+
+			"from information_schema.global_status "+
 			"where variable_name like 'wsrep_local_state' = 4").Scan(&v)
 
-	if err == sql.ErrNoRows || err != nil {
-		return false, ""
+	if err == sql.ErrNoRows {
+		return false, "", nil
 	}
 
-	return true, v
+	if err != nil {
+		return false, "", err
+	}
+
+	return true, v, nil
+}
+
+// collectMetrics Poll MySQL on a fixed interval and refresh the gauges served by /metrics
+func collectMetrics(interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), *queryTimeout)
+
+		if err := db.PingContext(ctx); err != nil {
+			atomic.StoreInt32(&mysqlUp, 0)
+		} else {
+			atomic.StoreInt32(&mysqlUp, 1)
+		}
+
+		if _, lagValue, err := replicaStatus(ctx, 0, ""); err == nil {
+			atomic.StoreInt64(&secondsBehind, int64(lagValue))
+		}
+
+		if ok, v, err := galeraClusterState(ctx); err == nil && ok {
+			if state, err := strconv.ParseInt(v, 10, 64); err == nil {
+				atomic.StoreInt64(&wsrepState, state)
+			}
+		}
+
+		if count, err := servingBinlogs(ctx); err == nil {
+			atomic.StoreInt64(&replicaCount, int64(count))
+		}
+
+		cancel()
+		time.Sleep(interval)
+	}
 }
 
 /*
@@ -387,55 +871,130 @@ func galeraClusterState() (bool, string) {
 // RouteStatusReadOnly ...
 func RouteStatusReadOnly(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database status: readOnly...")
-	isReadonly := readOnly()
 
-	routeResponse(w, isReadonly, "")
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReadonly, err := readOnly(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, isReadonly, "")
 }
 
 // RouteStatusReadWritable ...
 func RouteStatusReadWritable(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database status: readable and writable...")
-	isReadonly := readOnly()
 
-	routeResponse(w, !isReadonly, "")
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReadonly, err := readOnly(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, !isReadonly, "")
 }
 
 // RouteStatusSingle ...
 func RouteStatusSingle(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database status: single...")
-	isReadonly := readOnly()
-	isReplica, _, _ := isReplica()
-	isServeLogs := int2bool(servingBinlogs())
 
-	routeResponse(w, !isReadonly && !isReplica && !isServeLogs, "")
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReadonly, err := readOnly(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	isReplica, _, _, err := isReplica(ctx, "")
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	binlogCount, err := servingBinlogs(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, !isReadonly && !isReplica && !int2bool(binlogCount), "")
 }
 
 // RouteStatusLeader ...
 func RouteStatusLeader(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database status: leader...")
-	isReplica, _, _ := isReplica()
-	isServeLogs := int2bool(servingBinlogs())
 
-	routeResponse(w, !isReplica && isServeLogs, "")
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReplica, _, _, err := isReplica(ctx, "")
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	binlogCount, err := servingBinlogs(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, !isReplica && int2bool(binlogCount), "")
 }
 
 // RouteStatusFollower ...
 func RouteStatusFollower(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database status: follower...")
-	isReplica, _, _ := isReplica()
 
-	routeResponse(w, isReplica, "")
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReplica, _, _, err := isReplica(ctx, r.URL.Query().Get("channel"))
+	if handleDBError(w, start, err) {
+		return
+	}
 
+	routeResponse(w, start, isReplica, "")
 }
 
 // RouteStatusTopology ...
 func RouteStatusTopology(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database status: topology...")
-	isReplica, _, _ := isReplica()
-	replicaStatus, _ := replicaStatus(0)
-	isServeLogs := int2bool(servingBinlogs())
 
-	routeResponse(w, (!replicaStatus && isServeLogs) || isReplica, "")
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReplica, _, _, err := isReplica(ctx, "")
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	hasLag, _, err := replicaStatus(ctx, 0, "")
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	binlogCount, err := servingBinlogs(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	isServeLogs := int2bool(binlogCount)
+
+	routeResponse(w, start, (!hasLag && isServeLogs) || isReplica, "")
 }
 
 /*
@@ -446,41 +1005,117 @@ func RouteStatusTopology(w http.ResponseWriter, r *http.Request) {
 func RouteRoleMaster(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database role: master...")
 
+	start := time.Now()
+
 	if _, err := os.Stat("/master"); os.IsNotExist(err) {
-		isReadonly := readOnly()
-		isReplica, _, _ := isReplica()
-		isServeLogs := int2bool(servingBinlogs())
+		ctx, cancel := queryContext(r)
+		defer cancel()
+
+		isReadonly, err := readOnly(ctx)
+		if handleDBError(w, start, err) {
+			return
+		}
 
-		routeResponse(w, !isReadonly && !isReplica && isServeLogs, "")
+		isReplica, _, _, err := isReplica(ctx, "")
+		if handleDBError(w, start, err) {
+			return
+		}
+
+		binlogCount, err := servingBinlogs(ctx)
+		if handleDBError(w, start, err) {
+			return
+		}
+
+		routeResponse(w, start, !isReadonly && !isReplica && int2bool(binlogCount), "")
 	} else {
-		routeResponse(w, true, "")
+		routeResponse(w, start, true, "")
 	}
 }
 
 // RouteRoleReplica ...
 func RouteRoleReplica(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database role: replica...")
-	isReadonly := readOnly()
-	replicaStatus, _ := replicaStatus(0)
 
-	routeResponse(w, isReadonly && replicaStatus, "")
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReadonly, err := readOnly(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	hasLag, _, err := replicaStatus(ctx, 0, r.URL.Query().Get("channel"))
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, isReadonly && hasLag, "")
 }
 
 // RouteRoleReplicaByLag ...
 func RouteRoleReplicaByLag(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database role: replica by lag...")
-	isReadonly := readOnly()
-	replicaStatus, _ := replicaStatus(lag)
 
-	routeResponse(w, isReadonly && replicaStatus, "")
+	start := time.Now()
+
+	lagSegment := strings.TrimPrefix(r.URL.Path, "/role/replica/")
+
+	lagThreshold, err := strconv.Atoi(lagSegment)
+	if err != nil {
+		routeBadRequest(w, start, fmt.Errorf("invalid `lag` path segment %q: %w", lagSegment, err))
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReadonly, err := readOnly(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	hasLag, _, err := replicaStatus(ctx, lagThreshold, "")
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, isReadonly && hasLag, "")
 }
 
 // RouteRoleGalera ...
 func RouteRoleGalera(w http.ResponseWriter, r *http.Request) {
 	log.Print("Checking database role: galera...")
-	galeraClusterState, _ := galeraClusterState()
 
-	routeResponse(w, galeraClusterState, "")
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	inCluster, _, err := galeraClusterState(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, inCluster, "")
+}
+
+// RouteRoleGroupReplication ...
+func RouteRoleGroupReplication(w http.ResponseWriter, r *http.Request) {
+	log.Print("Checking database role: group replication...")
+
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	online, _, err := groupReplicationState(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, online, "")
 }
 
 /*
@@ -490,46 +1125,210 @@ func RouteRoleGalera(w http.ResponseWriter, r *http.Request) {
 // RouteReadGaleraState ...
 func RouteReadGaleraState(w http.ResponseWriter, r *http.Request) {
 	log.Print("Reading database state: galera...")
-	galeraClusterState, varValue := galeraClusterState()
 
-	routeResponse(w, galeraClusterState, varValue)
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	inCluster, varValue, err := galeraClusterState(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	routeResponse(w, start, inCluster, varValue)
 }
 
 // RouteReadReplicationLag ...
 func RouteReadReplicationLag(w http.ResponseWriter, r *http.Request) {
 	log.Print("Reading database replication: lag...")
+
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	channel := r.URL.Query().Get("channel")
+
+	isReplica, _, _, err := isReplica(ctx, channel)
+	if handleDBError(w, start, err) {
+		return
+	}
+
+	_, lagValue, err := replicaStatus(ctx, 0, channel)
+	if handleDBError(w, start, err) {
+		return
+	}
+
 	lagString := ""
-	isReplica, _, _ := isReplica()
-	_, lagValue := replicaStatus(0)
 
 	if isReplica {
 		lagString = strconv.Itoa(lagValue)
 	}
 
-	routeResponse(w, isReplica, lagString)
+	routeResponse(w, start, isReplica, lagString)
 }
 
 // RouteReadReplicationMaster ...
 func RouteReadReplicationMaster(w http.ResponseWriter, r *http.Request) {
 	log.Print("Reading database status: master...")
-	isReplica, masterIP, masterPort := isReplica()
+
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	isReplica, masterIP, masterPort, err := isReplica(ctx, "")
+	if handleDBError(w, start, err) {
+		return
+	}
 
 	if isReplica {
 		masterIP = masterIP + ":" + masterPort
 	}
 
-	routeResponse(w, isReplica, masterIP)
+	routeResponse(w, start, isReplica, masterIP)
 }
 
 // RouteReadReplicasCounter ...
 func RouteReadReplicasCounter(w http.ResponseWriter, r *http.Request) {
 	log.Print("Reading counter of database replications...")
+
+	start := time.Now()
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	binlogCount, err := servingBinlogs(ctx)
+	if handleDBError(w, start, err) {
+		return
+	}
+
 	lagString := "0"
-	isServeLogs := servingBinlogs()
 
-	if int2bool(isServeLogs) {
-		lagString = strconv.Itoa(isServeLogs)
+	if int2bool(binlogCount) {
+		lagString = strconv.Itoa(binlogCount)
 	}
 
-	routeResponse(w, int2bool(isServeLogs), lagString)
+	routeResponse(w, start, int2bool(binlogCount), lagString)
+}
+
+/*
+ * Aggregate routes
+ */
+
+// healthCheck names one probe run by RouteHealth
+type healthCheck struct {
+	name string
+	run  func(ctx context.Context) (bool, error)
+}
+
+// healthChecks lists the probes RouteHealth runs once each, so callers get a single
+// round-trip summary instead of querying every route above individually
+var healthChecks = []healthCheck{
+	{"read_only", func(ctx context.Context) (bool, error) {
+		return readOnly(ctx)
+	}},
+	{"replica", func(ctx context.Context) (bool, error) {
+		isReplica, _, _, err := isReplica(ctx, "")
+		return isReplica, err
+	}},
+	{"replication_lag", func(ctx context.Context) (bool, error) {
+		hasLag, _, err := replicaStatus(ctx, 0, "")
+		return hasLag, err
+	}},
+	{"serving_binlogs", func(ctx context.Context) (bool, error) {
+		count, err := servingBinlogs(ctx)
+		return int2bool(count), err
+	}},
+	{"galera", func(ctx context.Context) (bool, error) {
+		inCluster, _, err := galeraClusterState(ctx)
+		return inCluster, err
+	}},
+	{"group_replication", func(ctx context.Context) (bool, error) {
+		online, _, err := groupReplicationState(ctx)
+		return online, err
+	}},
+}
+
+// RouteHealth Runs every check once and returns the results as a single JSON array
+func RouteHealth(w http.ResponseWriter, r *http.Request) {
+	log.Print("Checking database health: aggregate...")
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	results := make([]HealthCheckResult, 0, len(healthChecks))
+
+	for _, check := range healthChecks {
+		start := time.Now()
+
+		status, err := check.run(ctx)
+		code := http.StatusOK
+		errMsg := ""
+
+		switch {
+		case err != nil:
+			status = false
+			errMsg = err.Error()
+
+			if errors.Is(err, ErrUnknownChannel) {
+				code = http.StatusBadRequest
+			} else {
+				code = http.StatusServiceUnavailable
+			}
+		case !status:
+			code = http.StatusForbidden
+		}
+
+		results = append(results, HealthCheckResult{
+			Check:        check.name,
+			HTTPResponse: buildEnvelope(start, code, status, "", errMsg),
+		})
+	}
+
+	response, _ := json.Marshal(results)
+	fmt.Fprintf(w, "%s", response)
+}
+
+/*
+ * Metrics route
+ */
+
+// RouteMetrics Expose request counters/latencies and MySQL gauges in Prometheus text format
+func RouteMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(contentType, "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP mysql_health_api_up Whether the last background db.Ping succeeded")
+	fmt.Fprintln(w, "# TYPE mysql_health_api_up gauge")
+	fmt.Fprintf(w, "mysql_health_api_up %d\n", atomic.LoadInt32(&mysqlUp))
+
+	fmt.Fprintln(w, "# HELP mysql_health_api_seconds_behind_master Seconds_Behind_Master reported by the last replication poll")
+	fmt.Fprintln(w, "# TYPE mysql_health_api_seconds_behind_master gauge")
+	fmt.Fprintf(w, "mysql_health_api_seconds_behind_master %d\n", atomic.LoadInt64(&secondsBehind))
+
+	fmt.Fprintln(w, "# HELP mysql_health_api_wsrep_local_state wsrep_local_state reported by the last Galera poll")
+	fmt.Fprintln(w, "# TYPE mysql_health_api_wsrep_local_state gauge")
+	fmt.Fprintf(w, "mysql_health_api_wsrep_local_state %d\n", atomic.LoadInt64(&wsrepState))
+
+	fmt.Fprintln(w, "# HELP mysql_health_api_replicas_connected Replicas currently being served binlogs")
+	fmt.Fprintln(w, "# TYPE mysql_health_api_replicas_connected gauge")
+	fmt.Fprintf(w, "mysql_health_api_replicas_connected %d\n", atomic.LoadInt64(&replicaCount))
+
+	fmt.Fprintln(w, "# HELP mysql_health_api_http_request_duration_ms Request latency in milliseconds, by route")
+	fmt.Fprintln(w, "# TYPE mysql_health_api_http_request_duration_ms histogram")
+
+	routeMetricsMu.Lock()
+	defer routeMetricsMu.Unlock()
+
+	for route, m := range routeCounters {
+		for i, le := range histogramBucketsMs {
+			fmt.Fprintf(w, "mysql_health_api_http_request_duration_ms_bucket{route=%q,le=%q} %d\n",
+				route, strconv.FormatFloat(le, 'f', -1, 64), m.buckets[i])
+		}
+
+		fmt.Fprintf(w, "mysql_health_api_http_request_duration_ms_bucket{route=%q,le=\"+Inf\"} %d\n", route, m.buckets[len(histogramBucketsMs)])
+		fmt.Fprintf(w, "mysql_health_api_http_request_duration_ms_sum{route=%q} %d\n", route, m.totalMs)
+		fmt.Fprintf(w, "mysql_health_api_http_request_duration_ms_count{route=%q} %d\n", route, m.count)
+	}
 }